@@ -0,0 +1,122 @@
+package tengo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+func init() {
+	// Register the concrete Object implementations that can appear in
+	// compiled constants so gob can encode/decode them through the
+	// Object interface when a Bytecode is cached via ScriptCache.
+	gob.Register(&Array{})
+	gob.Register(&Bool{})
+	gob.Register(&Bytes{})
+	gob.Register(&Char{})
+	gob.Register(&CompiledFunction{})
+	gob.Register(&Error{})
+	gob.Register(&Float{})
+	gob.Register(&ImmutableArray{})
+	gob.Register(&ImmutableMap{})
+	gob.Register(&Int{})
+	gob.Register(&Map{})
+	gob.Register(&String{})
+	gob.Register(&Time{})
+}
+
+// cacheablePayload is the subset of *Bytecode that MarshalBinary
+// actually persists. FileSet is deliberately excluded: see
+// MarshalBinary's comment.
+type cacheablePayload struct {
+	Constants    []Object
+	MainFunction *CompiledFunction
+}
+
+// MarshalBinary encodes the bytecode's constants and main function
+// (with the compiled function tree they reference) into a
+// self-contained binary blob suitable for caching to disk, e.g. via
+// ScriptCache. It fails rather than silently producing a corrupt entry
+// if any constant holds a *UserFunction: gob drops a func-typed field
+// without an error, so a native Go function constant would decode back
+// as a nil Value and panic the first time the cached script called it.
+//
+// b.FileSet is intentionally not part of the encoded payload.
+// parser.SourceFileSet records each file's line-offset table through
+// state populated internally during lexing, none of it exported, so
+// gob.Encode on it either hard-errors (encoding/gob refuses a struct
+// with no exported fields) or, for a SourceFileSet with at least one
+// exported field elsewhere in the type, silently drops the unexported
+// ones — decoding back a FileSet whose line/column lookups for runtime
+// error positions are simply wrong, with no error to catch it. Callers
+// that need accurate positions for a decoded Bytecode (ScriptCache.Load
+// in particular) are expected to attach a fresh FileSet sized to the
+// script's own source after calling UnmarshalBinary.
+func (b *Bytecode) MarshalBinary() ([]byte, error) {
+	if err := checkCacheableConstants(b.Constants); err != nil {
+		return nil, err
+	}
+
+	payload := cacheablePayload{
+		Constants:    b.Constants,
+		MainFunction: b.MainFunction,
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&payload); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a blob produced by MarshalBinary into b's
+// Constants and MainFunction. b.FileSet is left nil; see MarshalBinary.
+func (b *Bytecode) UnmarshalBinary(data []byte) error {
+	var payload cacheablePayload
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&payload); err != nil {
+		return err
+	}
+	b.Constants = payload.Constants
+	b.MainFunction = payload.MainFunction
+	b.FileSet = nil
+	return nil
+}
+
+// checkCacheableConstants walks constants (recursing into arrays and
+// maps) and reports an error for any *UserFunction it finds, since
+// those wrap a native Go func that cannot be round-tripped through
+// gob.
+func checkCacheableConstants(constants []Object) error {
+	for _, c := range constants {
+		if err := checkCacheableObject(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkCacheableObject(o Object) error {
+	switch v := o.(type) {
+	case *UserFunction:
+		return fmt.Errorf(
+			"tengo: cannot cache bytecode containing UserFunction"+
+				" constant %q: native Go functions cannot be serialized",
+			v.Name)
+	case *Array:
+		return checkCacheableConstants(v.Value)
+	case *ImmutableArray:
+		return checkCacheableConstants(v.Value)
+	case *Map:
+		for _, e := range v.Value {
+			if err := checkCacheableObject(e); err != nil {
+				return err
+			}
+		}
+	case *ImmutableMap:
+		for _, e := range v.Value {
+			if err := checkCacheableObject(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
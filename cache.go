@@ -0,0 +1,343 @@
+package tengo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/d5/tengo/v2/parser"
+)
+
+// cacheFormatVersion is bumped whenever the on-disk entry layout changes,
+// so stale entries written by an older binary are treated as misses
+// instead of being mis-decoded.
+const cacheFormatVersion = 1
+
+// cacheEntry is the on-disk representation of a compiled Script. It
+// carries everything Compile needs to rebuild a *Compiled without
+// re-parsing or re-compiling the source.
+type cacheEntry struct {
+	Version          int
+	GlobalIndexes    map[string]int
+	OutIdx           int
+	ContextIdx       int
+	NumGlobals       int
+	ConstObjectCount int    // bytecode.CountObjects() at Store time
+	BytecodeData     []byte // Bytecode.MarshalBinary output
+}
+
+// ScriptCache memoizes Script.Compile output on disk, keyed by an
+// ActionID computed from the script source and everything else that can
+// affect the compiled result (variable names, import settings, compile
+// flags, and the running tengo version). Modules served by the
+// ModuleGetter enter the key only by their names (or, when the getter
+// cannot list them, by its type), so changing a module's contents under
+// the same name does not invalidate an entry. It is a straightforward win
+// for CLIs and serverless-style embeddings that otherwise pay parse and
+// compile cost on every invocation.
+type ScriptCache struct {
+	dir     string
+	maxSize int64 // soft cap in bytes enforced by trim; <= 0 means unlimited
+}
+
+// NewScriptCache creates a ScriptCache backed by dir, creating dir if it
+// does not already exist.
+func NewScriptCache(dir string) (*ScriptCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &ScriptCache{dir: dir}, nil
+}
+
+// SetMaxSize sets a soft cap, in bytes, on the total size of the cache
+// directory. Store trims the least recently used entries once the cap
+// is exceeded. A value <= 0 disables the cap (the default).
+func (sc *ScriptCache) SetMaxSize(n int64) {
+	sc.maxSize = n
+}
+
+// actionID returns the cache key for s's current compile inputs.
+func (sc *ScriptCache) actionID(s *Script) string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "version:%s\n", tengoModuleVersion())
+
+	fmt.Fprintf(h, "source:%x\n", sha256.Sum256(s.input))
+
+	names := make([]string, 0, len(s.variables))
+	for name := range s.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(h, "vars:%s\n", strings.Join(names, ","))
+
+	fmt.Fprintf(h, "modules:%s\n", moduleFingerprint(s.modules))
+	fmt.Fprintf(h, "fileImport:%t importDir:%s defaultExt:%s contextVar:%s\n",
+		s.enableFileImport, s.importDir, s.defaultExt, s.contextVar)
+	fmt.Fprintf(h, "constants:%x\n", constantsFingerprint(s.constants))
+	fmt.Fprintf(h, "importTree:%x\n", importTreeFingerprint(s))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// constantsFingerprint hashes s.constants (set via Script.WithConstants)
+// so two scripts with identical source but different constants don't
+// collide on the same cache entry. This is only a cache key input, not
+// the persisted payload, so it's fine to ignore the encode error here:
+// Bytecode.MarshalBinary is what refuses to actually write an entry for
+// non-serializable constants.
+func constantsFingerprint(constants []Object) []byte {
+	var buf bytes.Buffer
+	_ = gob.NewEncoder(&buf).Encode(constants)
+	sum := sha256.Sum256(buf.Bytes())
+	return sum[:]
+}
+
+// importTreeFingerprint hashes the contents of every file under the
+// script's configured import source (importFS if set, else importDir
+// on the OS filesystem), so editing a file module without touching the
+// entry script still invalidates the cache instead of serving stale
+// compiled output indefinitely. It errs on the side of coarseness
+// (any change under the tree invalidates the entry, not just changes
+// to modules actually reachable from this script) since the compiler
+// itself is the only thing that knows the true import graph.
+func importTreeFingerprint(s *Script) []byte {
+	h := sha256.New()
+	if !s.enableFileImport {
+		return h.Sum(nil)
+	}
+
+	if s.importFS != nil {
+		_ = fs.WalkDir(s.importFS, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			data, rerr := fs.ReadFile(s.importFS, path)
+			if rerr != nil {
+				return nil
+			}
+			fmt.Fprintf(h, "%s:%x\n", path, sha256.Sum256(data))
+			return nil
+		})
+		return h.Sum(nil)
+	}
+
+	if s.importDir != "" {
+		_ = filepath.Walk(s.importDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			data, rerr := ioutil.ReadFile(path)
+			if rerr != nil {
+				return nil
+			}
+			rel, _ := filepath.Rel(s.importDir, path)
+			fmt.Fprintf(h, "%s:%x\n", rel, sha256.Sum256(data))
+			return nil
+		})
+	}
+	return h.Sum(nil)
+}
+
+// tengoModulePath is this package's module path, used to look up its
+// own version in build info rather than the consuming application's.
+const tengoModulePath = "github.com/d5/tengo/v2"
+
+// tengoModuleVersion returns the resolved version of the tengo module
+// itself (honoring replace directives), not bi.Main.Version, which is
+// the consuming application's version. Using the application's version
+// would leave a stale ActionID unchanged (and a version-mismatched
+// cached Bytecode silently decoded and run) whenever only the tengo
+// dependency is bumped without re-tagging the host app.
+func tengoModuleVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if bi.Main.Path == tengoModulePath {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path != tengoModulePath {
+			continue
+		}
+		if dep.Replace != nil {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return ""
+}
+
+// moduleFingerprint returns a content-derived, restart-stable
+// description of modules for use in an ActionID. %v on a ModuleGetter
+// backed by funcs or pointers (the common case) prints their
+// addresses, which move between process restarts (notably under a PIE
+// build) and would make the persistent cache this feature exists for
+// miss on almost every run.
+func moduleFingerprint(modules ModuleGetter) string {
+	if modules == nil {
+		return "<nil>"
+	}
+	if namer, ok := modules.(interface{ Names() []string }); ok {
+		names := append([]string(nil), namer.Names()...)
+		sort.Strings(names)
+		return fmt.Sprintf("%T:%s", modules, strings.Join(names, ","))
+	}
+	// No portable way to enumerate this ModuleGetter's contents; the
+	// type name is at least stable across restarts, unlike %v on a
+	// func/pointer-backed interface.
+	return fmt.Sprintf("%T", modules)
+}
+
+func (sc *ScriptCache) path(id string) string {
+	return filepath.Join(sc.dir, id)
+}
+
+// Load returns a *Compiled rebuilt from the cache entry for s, or nil if
+// there is no entry, or it is stale or unreadable. A cache miss (for any
+// reason) is treated as ordinary and never surfaced as an error; Compile
+// simply falls back to compiling from source.
+func (sc *ScriptCache) Load(s *Script) *Compiled {
+	path := sc.path(sc.actionID(s))
+
+	f, err := mmapFile(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(f.Data())).Decode(&entry); err != nil {
+		return nil
+	}
+	if entry.Version != cacheFormatVersion {
+		return nil
+	}
+
+	if s.maxConstObjects >= 0 && entry.ConstObjectCount > s.maxConstObjects {
+		// Compile() would reject this many constant objects for s; a
+		// looser (or unset) limit elsewhere must have written this
+		// entry. Treat it as a miss so s recompiles and enforces its
+		// own limit, rather than silently serving bytecode that
+		// bypasses it.
+		return nil
+	}
+
+	bytecode := &Bytecode{}
+	if err := bytecode.UnmarshalBinary(entry.BytecodeData); err != nil {
+		return nil
+	}
+	// UnmarshalBinary never sets FileSet (see Bytecode.MarshalBinary):
+	// rebuild one sized to s's own source, the same way Compile() does,
+	// so runtime error positions out of a cache-loaded script still
+	// resolve to real line/column numbers instead of a nil FileSet.
+	fileSet := parser.NewFileSet()
+	fileSet.AddFile(s.fileName, -1, len(s.input))
+	bytecode.FileSet = fileSet
+
+	globals := make([]Object, entry.NumGlobals)
+	for name, idx := range entry.GlobalIndexes {
+		if v, ok := s.variables[name]; ok {
+			globals[idx] = v.value
+		}
+	}
+
+	_ = os.Chtimes(path, time.Now(), time.Now()) // bump mtime for LRU trim
+
+	return &Compiled{
+		globalIndexes: entry.GlobalIndexes,
+		bytecode:      bytecode,
+		globals:       globals,
+		outIdx:        entry.OutIdx,
+		contextIdx:    entry.ContextIdx,
+		maxAllocs:     s.maxAllocs,
+	}
+}
+
+// Store persists compiled under the ActionID derived from s, writing
+// atomically via a temp file plus rename so concurrent Loads never see a
+// partial entry.
+func (sc *ScriptCache) Store(s *Script, compiled *Compiled) error {
+	bytecodeData, err := compiled.bytecode.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	entry := &cacheEntry{
+		Version:          cacheFormatVersion,
+		GlobalIndexes:    compiled.globalIndexes,
+		OutIdx:           compiled.outIdx,
+		ContextIdx:       compiled.contextIdx,
+		NumGlobals:       len(compiled.globals),
+		ConstObjectCount: compiled.bytecode.CountObjects(),
+		BytecodeData:     bytecodeData,
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(sc.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, sc.path(sc.actionID(s))); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return sc.trim()
+}
+
+// trim removes the least-recently-used entries until the cache directory
+// is back under SetMaxSize. It is a no-op if no size cap was set.
+func (sc *ScriptCache) trim() error {
+	if sc.maxSize <= 0 {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(sc.dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size()
+	}
+	for _, e := range entries {
+		if total <= sc.maxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(sc.dir, e.Name())); err != nil {
+			continue
+		}
+		total -= e.Size()
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package tengo
+
+import "io/ioutil"
+
+// mmapedFile is a read-only view of a cache entry file. On platforms
+// without a dedicated mmap implementation it falls back to a plain read.
+type mmapedFile struct {
+	data []byte
+}
+
+func (f *mmapedFile) Data() []byte { return f.data }
+
+func (f *mmapedFile) Close() error { return nil }
+
+func mmapFile(path string) (*mmapedFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapedFile{data: data}, nil
+}
@@ -0,0 +1,46 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package tengo
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapedFile is a read-only memory-mapped view of a cache entry file.
+type mmapedFile struct {
+	data []byte
+}
+
+func (f *mmapedFile) Data() []byte { return f.data }
+
+func (f *mmapedFile) Close() error {
+	if f.data == nil {
+		return nil
+	}
+	return syscall.Munmap(f.data)
+}
+
+func mmapFile(path string) (*mmapedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() == 0 {
+		return &mmapedFile{}, nil
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()),
+		syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapedFile{data: data}, nil
+}
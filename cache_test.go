@@ -0,0 +1,128 @@
+package tengo
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptCache_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewScriptCache(dir)
+	require.NoError(t, err)
+
+	s := NewScript([]byte(`out := 1 + 2`))
+	require.NoError(t, s.SetCache(dir))
+
+	c1, err := s.Compile()
+	require.NoError(t, err)
+	require.NoError(t, c1.Run())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "Store should have written exactly one entry file to the cache dir")
+
+	// Compile() itself also consults the cache, so getting the right
+	// answer from it a second time isn't proof of a disk hit: call
+	// Load directly against a script with identical cache-relevant
+	// fields and confirm it finds the entry Store just wrote.
+	other := NewScript([]byte(`out := 1 + 2`))
+	require.NoError(t, other.SetCache(dir))
+	loaded := cache.Load(other)
+	require.NotNil(t, loaded, "Load must hit the entry Store wrote for an identical script")
+	require.NoError(t, loaded.Run())
+
+	v1, _ := c1.Get("out").Value().(int64)
+	v2, _ := loaded.Get("out").Value().(int64)
+	require.Equal(t, v1, v2)
+}
+
+func TestScriptCache_PreservesRuntimeErrorPositions(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewScriptCache(dir)
+	require.NoError(t, err)
+
+	// A type error on line 2 so its reported position is unambiguous;
+	// Run fails here rather than at Compile, so the bad bytecode still
+	// gets cached.
+	src := []byte("a := 1\nout := a + \"x\"\n")
+
+	fresh := NewScript(src)
+	require.NoError(t, fresh.SetCache(dir))
+	c, err := fresh.Compile()
+	require.NoError(t, err)
+	freshErr := c.Run()
+	require.Error(t, freshErr)
+
+	cached := NewScript(src)
+	require.NoError(t, cached.SetCache(dir))
+	loaded := cache.Load(cached)
+	require.NotNil(t, loaded, "second script must load Store's cached entry rather than recompiling")
+	cachedErr := loaded.Run()
+	require.Error(t, cachedErr)
+
+	require.Equal(t, freshErr.Error(), cachedErr.Error(),
+		"a cache-loaded Bytecode must reconstruct a FileSet that reports the same source position as a fresh compile")
+}
+
+func TestScriptCache_RejectsUserFunctionConstants(t *testing.T) {
+	dir := t.TempDir()
+
+	// A builtin module compiles to an ImmutableMap constant, so its
+	// UserFunction ends up among the bytecode's constants.
+	mods := NewModuleMap()
+	mods.AddBuiltinModule("mod", map[string]Object{
+		"fn": &UserFunction{
+			Name: "fn",
+			Value: func(args ...Object) (Object, error) {
+				return UndefinedValue, nil
+			},
+		},
+	})
+	s := NewScript([]byte(`mod := import("mod"); out := 1`))
+	s.SetImports(mods)
+	require.NoError(t, s.SetCache(dir))
+
+	// Compile must still succeed even though caching the result fails;
+	// ScriptCache.Store errors are logged to s.trace, not propagated.
+	c, err := s.Compile()
+	require.NoError(t, err)
+	require.NoError(t, c.Run())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries, "Store must not write an entry for bytecode with UserFunction constants")
+}
+
+func TestScriptCache_EnforcesMaxConstObjects(t *testing.T) {
+	dir := t.TempDir()
+
+	loose := NewScript([]byte(`out := [1, 2, 3, 4, 5]`))
+	require.NoError(t, loose.SetCache(dir))
+	_, err := loose.Compile()
+	require.NoError(t, err)
+
+	strict := NewScript([]byte(`out := [1, 2, 3, 4, 5]`))
+	strict.SetMaxConstObjects(2)
+	require.NoError(t, strict.SetCache(dir))
+
+	_, err = strict.Compile()
+	require.Error(t, err, "strict's own limit must be enforced even when a looser cache entry already exists")
+}
+
+func TestImportTreeFingerprint_ChangesWithFileContent(t *testing.T) {
+	fsys := fstest.MapFS{
+		"mod.tengo": {Data: []byte(`export 1`)},
+	}
+	s := NewScript([]byte(`import("mod")`))
+	s.SetImportFS(fsys)
+	s.EnableFileImport(true)
+	before := importTreeFingerprint(s)
+
+	fsys["mod.tengo"] = &fstest.MapFile{Data: []byte(`export 2`)}
+	after := importTreeFingerprint(s)
+
+	require.NotEqual(t, before, after)
+}
@@ -0,0 +1,60 @@
+package tengo
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// SetImportFileExt sets the candidate extensions resolveModuleSource
+// tries when name has none of its own, in order.
+func (c *Compiler) SetImportFileExt(exts []string) {
+	c.importFileExt = exts
+}
+
+// SetImportFS sets the fs.FS that file imports are resolved against,
+// instead of the OS filesystem. When set, it takes precedence over
+// SetImportDir: resolveModuleSource reads candidate module paths
+// through fs.ReadFile rather than ioutil.ReadFile, so module path
+// resolution works the same way whether the source tree lives on disk,
+// in an embed.FS, or in memory.
+func (c *Compiler) SetImportFS(fsys fs.FS) {
+	c.importFS = fsys
+}
+
+// resolveModuleSource locates and reads the source for a file-based
+// import (as opposed to a named module served by ModuleGetter). name is
+// joined against the compiler's import directory and each of
+// fileImportExtensions in turn. It is only consulted when file imports
+// are enabled via EnableFileImport; otherwise it fails fast rather than
+// touching the filesystem at all.
+func (c *Compiler) resolveModuleSource(name string) (src []byte, resolvedPath string, err error) {
+	if !c.enableFileImport {
+		return nil, "", fmt.Errorf("file import is disabled")
+	}
+
+	for _, ext := range fileImportExtensions(name, c.importFileExt) {
+		path := filepath.Join(c.importDir, name+ext)
+
+		if c.importFS != nil {
+			src, err = fs.ReadFile(c.importFS, path)
+		} else {
+			src, err = ioutil.ReadFile(path)
+		}
+		if err == nil {
+			return src, path, nil
+		}
+	}
+	return nil, "", fmt.Errorf("module file not found: %s", name)
+}
+
+// fileImportExtensions returns the candidate extensions to try when
+// resolving name: only name as-is (the empty extension) if it already has
+// an extension of its own, otherwise each configured default extension.
+func fileImportExtensions(name string, defaultExts []string) []string {
+	if filepath.Ext(name) != "" {
+		return []string{""}
+	}
+	return defaultExts
+}
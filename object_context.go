@@ -0,0 +1,99 @@
+package tengo
+
+import "context"
+
+// Context is a tengo Object that exposes a Go context.Context to script
+// code. Script.SetContextVar names a global that RunContext populates
+// with a Context wrapping the caller's context.Context before each run,
+// so scripts can cooperatively check deadlines and cancellation (e.g.
+// `if is_error(ctx.err()) { return }`) and read request-scoped values
+// via ctx.value(key).
+type Context struct {
+	ObjectImpl
+	Value context.Context
+}
+
+// TypeName returns the name of the type.
+func (c *Context) TypeName() string {
+	return "context"
+}
+
+func (c *Context) String() string {
+	return "<context>"
+}
+
+// Copy returns a copy of the type.
+func (c *Context) Copy() Object {
+	return &Context{Value: c.Value}
+}
+
+// Equals returns true if an given object is equal to this object.
+func (c *Context) Equals(o Object) bool {
+	other, ok := o.(*Context)
+	return ok && other.Value == c.Value
+}
+
+// IsFalsy returns true if the value of the type is falsy.
+func (c *Context) IsFalsy() bool {
+	return c.Value == nil
+}
+
+// IndexGet returns the callable method identified by index: deadline(),
+// err(), done() and value(key).
+func (c *Context) IndexGet(index Object) (Object, error) {
+	name, ok := index.(*String)
+	if !ok {
+		return UndefinedValue, nil
+	}
+
+	switch name.Value {
+	case "deadline":
+		return &UserFunction{
+			Name: "deadline",
+			Value: func(args ...Object) (Object, error) {
+				d, ok := c.Value.Deadline()
+				if !ok {
+					return UndefinedValue, nil
+				}
+				return &Time{Value: d}, nil
+			},
+		}, nil
+	case "err":
+		return &UserFunction{
+			Name: "err",
+			Value: func(args ...Object) (Object, error) {
+				if err := c.Value.Err(); err != nil {
+					return &Error{Value: &String{Value: err.Error()}}, nil
+				}
+				return UndefinedValue, nil
+			},
+		}, nil
+	case "done":
+		return &UserFunction{
+			Name: "done",
+			Value: func(args ...Object) (Object, error) {
+				select {
+				case <-c.Value.Done():
+					return TrueValue, nil
+				default:
+					return FalseValue, nil
+				}
+			},
+		}, nil
+	case "value":
+		return &UserFunction{
+			Name: "value",
+			Value: func(args ...Object) (Object, error) {
+				if len(args) != 1 {
+					return nil, ErrWrongNumArguments
+				}
+				v := c.Value.Value(ToInterface(args[0]))
+				if v == nil {
+					return UndefinedValue, nil
+				}
+				return FromInterface(v)
+			},
+		}, nil
+	}
+	return UndefinedValue, nil
+}
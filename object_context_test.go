@@ -0,0 +1,54 @@
+package tengo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_IsFalsyOnNilValue(t *testing.T) {
+	var c Context
+	require.True(t, c.IsFalsy())
+
+	c.Value = context.Background()
+	require.False(t, c.IsFalsy())
+}
+
+func TestContext_IndexGet_Done(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := &Context{Value: ctx}
+	doneFn, err := c.IndexGet(&String{Value: "done"})
+	require.NoError(t, err)
+	fn, ok := doneFn.(*UserFunction)
+	require.True(t, ok)
+
+	result, err := fn.Value()
+	require.NoError(t, err)
+	require.Equal(t, FalseValue, result)
+
+	cancel()
+	result, err = fn.Value()
+	require.NoError(t, err)
+	require.Equal(t, TrueValue, result)
+}
+
+func TestContext_IndexGet_Err(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	c := &Context{Value: ctx}
+	errFn, err := c.IndexGet(&String{Value: "err"})
+	require.NoError(t, err)
+	fn := errFn.(*UserFunction)
+
+	result, err := fn.Value()
+	require.NoError(t, err)
+	e, ok := result.(*Error)
+	require.True(t, ok)
+	require.Contains(t, e.String(), context.DeadlineExceeded.Error())
+}
@@ -2,8 +2,10 @@ package tengo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"path/filepath"
 	"sync"
@@ -20,10 +22,13 @@ type Script struct {
 	maxConstObjects  int
 	enableFileImport bool
 	importDir        string
+	importFS         fs.FS
 	fileName         string
 	defaultExt       string
 	constants        []Object
 	trace            io.Writer
+	cache            *ScriptCache
+	contextVar       string
 }
 
 // NewScript creates a Script instance with an input script.
@@ -109,6 +114,38 @@ func (s *Script) SetImportDir(dir string) error {
 	return nil
 }
 
+// SetImportFS sets the fs.FS that file imports are resolved against,
+// instead of the OS filesystem. This lets scripts be imported from an
+// embed.FS, an in-memory tree, a zip archive, or any other fs.FS
+// implementation, e.g. for shipping scripts inside a Go binary via
+// //go:embed. When set, it takes precedence over SetImportDir.
+func (s *Script) SetImportFS(fsys fs.FS) {
+	s.importFS = fsys
+}
+
+// SetCache memoizes this script's Compile output under dir, keyed by a
+// hash of the source and everything that affects compilation (see
+// ScriptCache for details). Repeated compiles of the same script, e.g.
+// across process restarts of a CLI or serverless-style embedding, can
+// then skip parsing and compilation on a cache hit.
+func (s *Script) SetCache(dir string) error {
+	sc, err := NewScriptCache(dir)
+	if err != nil {
+		return err
+	}
+	s.cache = sc
+	return nil
+}
+
+// SetContextVar reserves name as a global that RunContext populates with
+// a Context object wrapping the caller's context.Context before each
+// run. Script code can then read it like any other global, e.g.
+// `if is_error(ctx.err()) { return }`, to cooperatively check deadlines
+// and cancellation, or read request-scoped values via ctx.value(key).
+func (s *Script) SetContextVar(name string) {
+	s.contextVar = name
+}
+
 // SetMaxAllocs sets the maximum number of objects allocations during the run
 // time. Compiled script will return ErrObjectAllocLimit error if it
 // exceeds this limit.
@@ -131,7 +168,13 @@ func (s *Script) EnableFileImport(enable bool) {
 // Compile compiles the script with all the defined variables, and, returns
 // Compiled object.
 func (s *Script) Compile() (*Compiled, error) {
-	symbolTable, globals, err := s.prepCompile()
+	if s.cache != nil {
+		if compiled := s.cache.Load(s); compiled != nil {
+			return compiled, nil
+		}
+	}
+
+	symbolTable, globals, contextIdx, err := s.prepCompile()
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +190,12 @@ func (s *Script) Compile() (*Compiled, error) {
 	c := NewCompiler(srcFile, symbolTable, s.constants, s.modules, s.trace)
 	c.EnableFileImport(s.enableFileImport)
 	c.SetImportDir(s.importDir)
+	c.SetImportFileExt([]string{s.defaultExt})
+	c.SetImportFS(s.importFS)
+	if s.importFS != nil {
+		// s.importDir is an absolute OS path, not a valid fs.FS path.
+		c.SetImportDir("")
+	}
 	if err := c.Compile(file); err != nil {
 		return nil, err
 	}
@@ -154,6 +203,10 @@ func (s *Script) Compile() (*Compiled, error) {
 	// reduce globals size
 	globals = globals[:symbolTable.MaxSymbols()+1]
 
+	// reserve a global slot to hold the result of Compiled.Call/CallContext
+	outIdx := len(globals)
+	globals = append(globals, nil)
+
 	// global symbol names to indexes
 	globalIndexes := make(map[string]int, len(globals))
 	for _, name := range symbolTable.Names() {
@@ -174,12 +227,21 @@ func (s *Script) Compile() (*Compiled, error) {
 			return nil, fmt.Errorf("exceeding constant objects limit: %d", cnt)
 		}
 	}
-	return &Compiled{
+	compiled := &Compiled{
 		globalIndexes: globalIndexes,
 		bytecode:      bytecode,
 		globals:       globals,
+		outIdx:        outIdx,
+		contextIdx:    contextIdx,
 		maxAllocs:     s.maxAllocs,
-	}, nil
+	}
+
+	if s.cache != nil {
+		if err := s.cache.Store(s, compiled); err != nil && s.trace != nil {
+			fmt.Fprintf(s.trace, "script cache: %v\n", err)
+		}
+	}
+	return compiled, nil
 }
 
 // Run compiles and runs the scripts. Use returned compiled object to access
@@ -208,6 +270,7 @@ func (s *Script) RunContext(
 func (s *Script) prepCompile() (
 	symbolTable *SymbolTable,
 	globals []Object,
+	contextIdx int,
 	err error,
 ) {
 	var names []string
@@ -230,6 +293,12 @@ func (s *Script) prepCompile() (
 		}
 		globals[symbol.Index] = s.variables[name].value
 	}
+
+	contextIdx = -1
+	if s.contextVar != "" {
+		symbol := symbolTable.Define(s.contextVar)
+		contextIdx = symbol.Index
+	}
 	return
 }
 
@@ -239,7 +308,8 @@ type Compiled struct {
 	globalIndexes map[string]int // global symbol name to index
 	bytecode      *Bytecode
 	globals       []Object
-	outIdx        int
+	outIdx        int // reserved global index that holds the result of Call/CallContext
+	contextIdx    int // global index that holds the Context object for RunContext, or -1 if unused
 	maxAllocs     int64
 	lock          sync.RWMutex
 }
@@ -258,7 +328,12 @@ func (c *Compiled) RunContext(ctx context.Context) (err error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	if c.contextIdx >= 0 {
+		c.globals[c.contextIdx] = &Context{Value: ctx}
+	}
+
 	v := NewVM(c.bytecode, c.globals, c.maxAllocs)
+	v.SetContext(ctx)
 	ch := make(chan error, 1)
 	go func() {
 		defer func() {
@@ -286,96 +361,184 @@ func (c *Compiled) RunContext(ctx context.Context) (err error) {
 	return
 }
 
+// RunContextConcurrent is like RunContext, but instead of locking
+// Compiled for the whole VM execution, it takes a snapshot copy of the
+// compile-time globals for this call only and runs against that copy,
+// so many goroutines can invoke the same Compiled concurrently without
+// Clone-ing it first. overrides, if non-nil, replaces the named globals
+// in the snapshot before running (e.g. per-request input variables).
+// The returned *Compiled shares the bytecode with c but holds this
+// call's own globals, so its Get/GetAll/IsDefined reflect only this
+// invocation and are safe to read without racing other calls. This
+// makes Compiled reusable as a "prepared statement" for things like
+// HTTP handlers and worker pools.
+func (c *Compiled) RunContextConcurrent(
+	ctx context.Context,
+	overrides map[string]interface{},
+) (*Compiled, error) {
+	c.lock.RLock()
+	globals := make([]Object, len(c.globals))
+	copy(globals, c.globals)
+	globalIndexes := c.globalIndexes
+	bytecode := c.bytecode
+	outIdx := c.outIdx
+	contextIdx := c.contextIdx
+	maxAllocs := c.maxAllocs
+	c.lock.RUnlock()
+
+	for name, value := range overrides {
+		idx, ok := globalIndexes[name]
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not defined", name)
+		}
+		obj, err := FromInterface(value)
+		if err != nil {
+			return nil, err
+		}
+		globals[idx] = obj
+	}
+
+	// A nil ctx must not reach &Context{Value: ctx}: any of
+	// ctx.err()/deadline()/done()/value() would then dispatch through a
+	// nil context.Context and panic. RunContextConcurrent supports
+	// ctx == nil (see below), so fall back to a non-cancelable
+	// placeholder instead.
+	wrapped := ctx
+	if wrapped == nil {
+		wrapped = context.Background()
+	}
+	if contextIdx >= 0 {
+		globals[contextIdx] = &Context{Value: wrapped}
+	}
+
+	invocation := &Compiled{
+		globalIndexes: globalIndexes,
+		bytecode:      bytecode,
+		globals:       globals,
+		outIdx:        outIdx,
+		contextIdx:    contextIdx,
+		maxAllocs:     maxAllocs,
+	}
+
+	v := NewVM(bytecode, globals, maxAllocs)
+	v.SetContext(wrapped)
+
+	var err error
+	if ctx == nil {
+		err = v.Run()
+	} else {
+		err = runVMContext(ctx, v)
+	}
+	return invocation, err
+}
+
+// Call calls a callable tengo.Object (e.g. a script-defined function
+// obtained via Compiled.Get) with the given arguments, and returns its
+// result. args must be convertible to supported Tengo types via
+// FromInterface.
 //
-//// Call calls callable tengo.Object with given arguments, and returns result.
-//// args must be convertible to supported Tengo types.
-//func (c *Compiled) Call(fn Object,
-//	args ...interface{}) (interface{}, error) {
-//	return c.CallContext(nil, fn, args...)
-//}
-//
-//// CallContext calls callable tengo.Object with given arguments, and returns result.
-//// args must be convertible to supported Tengo types.
-//func (c *Compiled) CallContext(ctx context.Context, fn Object,
-//	args ...interface{}) (interface{}, error) {
-//	c.lock.Lock()
-//	defer c.lock.Unlock()
-//
-//	if fn == nil {
-//		return nil, errors.New("callable expected, got nil")
-//	}
-//	if !fn.CanCall() {
-//		return nil, errors.New("not a callable")
-//	}
-//
-//	return c.call(ctx, fn, args...)
-//}
-//
-//func (c *Compiled) call(ctx context.Context, cfn Object,
-//	args ...interface{}) (interface{}, error) {
-//	targs := make([]Object, 0, len(args))
-//	for i := range args {
-//		v, err := FromInterface(args[i])
-//		if err != nil {
-//			return nil, err
-//		}
-//		targs = append(targs, v)
-//	}
-//
-//	v, err := c.callCompiled(ctx, cfn, targs...)
-//	if err != nil {
-//		return nil, err
-//	}
-//	return ToInterface(v), nil
-//}
-//
-//func (c *Compiled) callCompiled(ctx context.Context, fn Object,
-//	args ...Object) (Object, error) {
-//
-//	constsOffset := len(c.bytecode.Constants)
-//
-//	// Load fn
-//	inst := MakeInstruction(parser.OpConstant, constsOffset)
-//
-//	// Load args
-//	for i := range args {
-//		inst = append(inst,
-//			MakeInstruction(parser.OpConstant, constsOffset+i+1)...)
-//	}
-//
-//	// Call, set value to a global, stop
-//	inst = append(inst, MakeInstruction(parser.OpCall, len(args))...)
-//	inst = append(inst, MakeInstruction(parser.OpSetGlobal, c.outIdx)...)
-//	inst = append(inst, MakeInstruction(parser.OpSuspend)...)
-//
-//	c.bytecode.Constants = append(c.bytecode.Constants, fn)
-//	c.bytecode.Constants = append(c.bytecode.Constants, args...)
-//
-//	// orig := s.bytecode.MainFunction
-//	c.bytecode.MainFunction = &CompiledFunction{
-//		Instructions: inst,
-//	}
-//
-//	var err error
-//	if ctx == nil {
-//		vm := NewVM(c.bytecode, c.globals, c.maxAllocs)
-//		err = vm.Run()
-//	} else {
-//		vm := NewVM(c.bytecode, c.globals, c.maxAllocs)
-//		err = runVMContext(ctx, vm)
-//	}
-//
-//	// TODO: go back to normal if required
-//	// s.bytecode.MainFunction = orig
-//	// avoid memory leak.
-//	for i := constsOffset; i < len(c.bytecode.Constants); i++ {
-//		c.bytecode.Constants[i] = nil
-//	}
-//	c.bytecode.Constants = c.bytecode.Constants[:constsOffset]
-//
-//	// get symbol using index and return it
-//	return c.globals[c.outIdx], err
-//}
+// Call runs fn against a private snapshot of c's globals (see
+// callCompiled), not c.globals itself, so that concurrent Call/
+// CallContext invocations (and a concurrently running Run/RunContext)
+// never race each other. Any global variable fn assigns to is only
+// visible for the duration of that one call: it is not copied back into
+// c.globals, so it is invisible to c.Get/GetAll and to any later Run,
+// RunContext, or Call. Use Run/RunContext if a script-defined function
+// needs to durably mutate script globals.
+func (c *Compiled) Call(fn Object,
+	args ...interface{}) (interface{}, error) {
+	return c.CallContext(nil, fn, args...)
+}
+
+// CallContext is like Call but includes a context that can be used to
+// abort the call, the same way RunContext aborts a script run. It has
+// the same frozen-globals-snapshot semantics as Call.
+func (c *Compiled) CallContext(ctx context.Context, fn Object,
+	args ...interface{}) (interface{}, error) {
+	if fn == nil {
+		return nil, errors.New("callable expected, got nil")
+	}
+	if !fn.CanCall() {
+		return nil, errors.New("not a callable")
+	}
+
+	targs := make([]Object, len(args))
+	for i := range args {
+		v, err := FromInterface(args[i])
+		if err != nil {
+			return nil, err
+		}
+		targs[i] = v
+	}
+
+	v, err := c.callCompiled(ctx, fn, targs...)
+	if err != nil {
+		return nil, err
+	}
+	return ToInterface(v), nil
+}
+
+// callCompiled runs fn(args...) on a dedicated VM instance. It builds a
+// synthetic bytecode from a copy of the compiled constants with fn/args
+// appended as new constants and a minimal main function that calls fn,
+// and runs it on a snapshot of the globals, storing the result in that
+// snapshot's reserved out global, so the
+// original bytecode, its constants and c.globals are never mutated and
+// callCompiled is safe to call concurrently (including while Run/
+// RunContext are executing elsewhere).
+func (c *Compiled) callCompiled(ctx context.Context, fn Object,
+	args ...Object) (Object, error) {
+	c.lock.RLock()
+	globals := make([]Object, len(c.globals))
+	copy(globals, c.globals)
+	bytecode := c.callBytecode(fn, args)
+	maxAllocs := c.maxAllocs
+	c.lock.RUnlock()
+
+	vm := NewVM(bytecode, globals, maxAllocs)
+	vm.SetContext(ctx)
+
+	var err error
+	if ctx == nil {
+		err = vm.Run()
+	} else {
+		err = runVMContext(ctx, vm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return globals[c.outIdx], nil
+}
+
+// callBytecode builds a standalone *Bytecode that loads fn and args as
+// constants appended after a copy of the compiled constants, calls fn,
+// stores the result in the reserved "out" global, then suspends. The
+// original c.bytecode.Constants slice is copied rather than appended to
+// in place, so it (and any bytecode shared with other Compiled clones)
+// is left untouched.
+func (c *Compiled) callBytecode(fn Object, args []Object) *Bytecode {
+	constsOffset := len(c.bytecode.Constants)
+
+	constants := make([]Object, constsOffset, constsOffset+1+len(args))
+	copy(constants, c.bytecode.Constants)
+	constants = append(constants, fn)
+	constants = append(constants, args...)
+
+	inst := MakeInstruction(parser.OpConstant, constsOffset)
+	for i := range args {
+		inst = append(inst,
+			MakeInstruction(parser.OpConstant, constsOffset+i+1)...)
+	}
+	inst = append(inst, MakeInstruction(parser.OpCall, len(args))...)
+	inst = append(inst, MakeInstruction(parser.OpSetGlobal, c.outIdx)...)
+	inst = append(inst, MakeInstruction(parser.OpSuspend)...)
+
+	return &Bytecode{
+		FileSet:      c.bytecode.FileSet,
+		Constants:    constants,
+		MainFunction: &CompiledFunction{Instructions: inst},
+	}
+}
 
 func runVMContext(ctx context.Context, vm *VM) (err error) {
 	errch := make(chan error)
@@ -405,6 +568,8 @@ func (c *Compiled) Clone() *Compiled {
 		globalIndexes: c.globalIndexes,
 		bytecode:      c.bytecode,
 		globals:       make([]Object, len(c.globals)),
+		outIdx:        c.outIdx,
+		contextIdx:    c.contextIdx,
 		maxAllocs:     c.maxAllocs,
 	}
 	// copy global objects
@@ -0,0 +1,98 @@
+package tengo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompiled_CallConcurrentSafety(t *testing.T) {
+	s := NewScript([]byte(`
+square := func(x) { return x * x }
+`))
+	c, err := s.Compile()
+	require.NoError(t, err)
+	require.NoError(t, c.Run())
+
+	fn := c.Get("square").Object()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := c.Call(fn, i)
+			require.NoError(t, err)
+			require.Equal(t, int64(i*i), out)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCompiled_Call_DoesNotPersistGlobalMutation(t *testing.T) {
+	s := NewScript([]byte(`
+counter := 0
+bump := func() { counter = counter + 1 }
+`))
+	c, err := s.Compile()
+	require.NoError(t, err)
+	require.NoError(t, c.Run())
+
+	fn := c.Get("bump").Object()
+	_, err = c.Call(fn)
+	require.NoError(t, err)
+	_, err = c.Call(fn)
+	require.NoError(t, err)
+
+	// bump mutates the "counter" global on Call's private snapshot, not
+	// c.globals, so repeated Calls never observe each other's effect and
+	// a later Run still sees the value Run itself last set.
+	require.Equal(t, int64(0), c.Get("counter").Value())
+}
+
+func TestScript_SetImportFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"mod.tengo": {Data: []byte(`export 42`)},
+	}
+
+	s := NewScript([]byte(`out := import("mod")`))
+	s.EnableFileImport(true)
+	s.SetImportFS(fsys)
+
+	c, err := s.Compile()
+	require.NoError(t, err)
+	require.NoError(t, c.Run())
+	require.Equal(t, int64(42), c.Get("out").Value())
+}
+
+func TestCompiled_RunContextConcurrent_NilContext(t *testing.T) {
+	s := NewScript([]byte(`out := 1`))
+	s.SetContextVar("ctx")
+	c, err := s.Compile()
+	require.NoError(t, err)
+
+	invocation, err := c.RunContextConcurrent(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), invocation.Get("out").Value())
+}
+
+func TestCompiled_RunContextConcurrent_Overrides(t *testing.T) {
+	s := NewScript([]byte(`out := in * 2`))
+	require.NoError(t, s.Add("in", 0))
+
+	c, err := s.Compile()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	a, err := c.RunContextConcurrent(ctx, map[string]interface{}{"in": 5})
+	require.NoError(t, err)
+	b, err := c.RunContextConcurrent(ctx, map[string]interface{}{"in": 9})
+	require.NoError(t, err)
+
+	require.Equal(t, int64(10), a.Get("out").Value())
+	require.Equal(t, int64(18), b.Get("out").Value())
+}
@@ -0,0 +1,28 @@
+package tengo
+
+import "context"
+
+// SetContext attaches ctx to v so that native UserFunction builtins
+// running on v can retrieve it via v.Context(), without needing the
+// running script to pass its Context global around explicitly. A nil
+// ctx clears it back to context.Background(). This is orthogonal to
+// RunContext/RunContextConcurrent's cancellation-on-ctx.Done()
+// behavior: it only makes ctx readable, it does not itself abort v.
+func (v *VM) SetContext(ctx context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	v.ctx = ctx
+}
+
+// Context returns the context most recently set on v via SetContext, or
+// context.Background() if none was set. UserFunction implementations
+// that close over the *VM they were registered against can call this to
+// observe cancellation or read request-scoped values without the script
+// having to thread its Context global through every call.
+func (v *VM) Context() context.Context {
+	if v.ctx == nil {
+		return context.Background()
+	}
+	return v.ctx
+}
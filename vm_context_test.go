@@ -0,0 +1,28 @@
+package tengo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVM_Context_DefaultsToBackground(t *testing.T) {
+	v := &VM{}
+	require.Equal(t, context.Background(), v.Context())
+}
+
+func TestVM_Context_SetAndGet(t *testing.T) {
+	v := &VM{}
+	ctx := context.WithValue(context.Background(), struct{}{}, "request-id")
+
+	v.SetContext(ctx)
+	require.Equal(t, ctx, v.Context())
+}
+
+func TestVM_Context_SetNilFallsBackToBackground(t *testing.T) {
+	v := &VM{}
+	v.SetContext(context.Background())
+	v.SetContext(nil)
+	require.Equal(t, context.Background(), v.Context())
+}